@@ -0,0 +1,85 @@
+package view
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// Add registers a single cidr/area pair, building the updated trie off
+// to the side and publishing it with one atomic store, the same
+// copy-on-write swap Reload uses, so concurrent Lookups never observe a
+// partial insert. It returns ErrDuplicate if cidr is already registered.
+func (v *View) Add(cidr, area string) error {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return errBadSubnet
+	}
+
+	key, bits := viewKey(prefix.Addr(), prefix.Bits())
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	newRoot, err := viewInsert(v.root.Load(), key, bits, &ViewInfo{area: area, subnet: cidr, v4: prefix.Addr().Is4()})
+	if err != nil {
+		return err
+	}
+
+	v.root.Store(newRoot)
+
+	return nil
+}
+
+// Remove unregisters cidr, pruning any interior viewNode left empty by
+// its removal so the trie stays as compact as if cidr had never been
+// inserted. Like Add, it clones the path to the removed route instead of
+// mutating the live tree, so concurrent Lookups never observe a partial
+// removal. It returns ErrNotFound if cidr is not registered.
+func (v *View) Remove(cidr string) error {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return errBadSubnet
+	}
+
+	key, bits := viewKey(prefix.Addr(), prefix.Bits())
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	newRoot, removed := viewRemove(v.root.Load(), key, bits)
+	if !removed {
+		return ErrNotFound
+	}
+
+	if newRoot == nil {
+		newRoot = &viewNode{bit: viewMaxBits}
+	}
+
+	v.root.Store(newRoot)
+
+	return nil
+}
+
+// Replace rebuilds the view from cidrs (subnet to area) wholesale and
+// swaps it in atomically. The new table is built and fully validated
+// before anything is published: a bad cidr leaves the current table
+// untouched and Replace returns the parse error.
+func (v *View) Replace(cidrs map[string]string) error {
+	root := &viewNode{bit: viewMaxBits}
+
+	for cidr, area := range cidrs {
+		var err error
+
+		root, err = viewSubnet(root, []byte(cidr), []byte(area))
+		if err != nil {
+			return fmt.Errorf("%s: %s", err.Error(), cidr)
+		}
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.root.Store(root)
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package view
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestAddDuplicate checks that Add rejects re-registering an already
+// registered subnet with ErrDuplicate, leaving the original route intact.
+func TestAddDuplicate(t *testing.T) {
+	v := New()
+
+	if err := v.Add("10.0.0.0/8", "A"); err != nil {
+		t.Fatalf("first Add: %v", err)
+	}
+
+	if err := v.Add("10.0.0.0/8", "B"); err != ErrDuplicate {
+		t.Errorf("second Add = %v, want ErrDuplicate", err)
+	}
+
+	got, err := v.Lookup("10.1.2.3")
+	if err != nil || got == nil || got.area != "A" {
+		t.Errorf("Lookup(10.1.2.3) = %v, %v, want area A", got, err)
+	}
+}
+
+// TestAddBadSubnet checks that Add rejects an unparseable cidr with
+// errBadSubnet.
+func TestAddBadSubnet(t *testing.T) {
+	if err := New().Add("not-a-cidr", "A"); err != errBadSubnet {
+		t.Errorf("Add(not-a-cidr) = %v, want errBadSubnet", err)
+	}
+}
+
+// TestRemoveNotFound checks that Remove rejects a subnet that was never
+// registered with ErrNotFound.
+func TestRemoveNotFound(t *testing.T) {
+	v := New()
+
+	if err := v.Remove("10.0.0.0/8"); err != ErrNotFound {
+		t.Errorf("Remove(unregistered) = %v, want ErrNotFound", err)
+	}
+
+	if err := v.Add("10.0.0.0/8", "A"); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := v.Remove("10.0.0.0/8"); err != nil {
+		t.Fatalf("first Remove: %v", err)
+	}
+	if err := v.Remove("10.0.0.0/8"); err != ErrNotFound {
+		t.Errorf("second Remove = %v, want ErrNotFound", err)
+	}
+}
+
+// TestReplace checks that Replace rebuilds the view wholesale, dropping
+// routes absent from the new set, and leaves the current table untouched
+// when given a bad cidr.
+func TestReplace(t *testing.T) {
+	v := buildView(t, map[string]string{"10.0.0.0/8": "A"})
+
+	if err := v.Replace(map[string]string{"192.168.0.0/16": "B"}); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+
+	if got, _ := v.Lookup("10.1.2.3"); got != nil {
+		t.Errorf("Lookup(10.1.2.3) = %v, want nil after Replace", got)
+	}
+	if got, _ := v.Lookup("192.168.1.1"); got == nil || got.area != "B" {
+		t.Errorf("Lookup(192.168.1.1) = %v, want area B", got)
+	}
+
+	if err := v.Replace(map[string]string{"bad-cidr": "C"}); err == nil {
+		t.Error("Replace(bad-cidr) = nil error, want a parse error")
+	}
+	if got, _ := v.Lookup("192.168.1.1"); got == nil || got.area != "B" {
+		t.Errorf("Lookup(192.168.1.1) after failed Replace = %v, want area B unchanged", got)
+	}
+}
+
+// TestAddConcurrentWithLookup exercises Add and Lookup on the same View
+// concurrently under the race detector: Lookup never takes v.mu (see
+// View.mu), so it only stays safe if Add clones the path to every node it
+// changes instead of mutating the live, already-published trie in place
+// (see viewInsert).
+func TestAddConcurrentWithLookup(t *testing.T) {
+	v := New()
+
+	var wg sync.WaitGroup
+
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < 2000; i++ {
+			cidr := fmt.Sprintf("10.%d.%d.0/24", i/256, i%256)
+			if err := v.Add(cidr, "area"); err != nil {
+				t.Errorf("Add(%s): %v", cidr, err)
+			}
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < 2000; i++ {
+			v.Lookup(fmt.Sprintf("10.%d.%d.1", i/256, i%256))
+		}
+	}()
+
+	wg.Wait()
+}
+
+// TestRemoveConcurrentWithLookup is TestAddConcurrentWithLookup's
+// counterpart for Remove, which clones the path to the removed route the
+// same way Add clones the path to an inserted one (see viewRemove).
+func TestRemoveConcurrentWithLookup(t *testing.T) {
+	v := New()
+
+	const n = 2000
+
+	for i := 0; i < n; i++ {
+		cidr := fmt.Sprintf("10.%d.%d.0/24", i/256, i%256)
+		if err := v.Add(cidr, "area"); err != nil {
+			t.Fatalf("Add(%s): %v", cidr, err)
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < n; i++ {
+			cidr := fmt.Sprintf("10.%d.%d.0/24", i/256, i%256)
+			if err := v.Remove(cidr); err != nil {
+				t.Errorf("Remove(%s): %v", cidr, err)
+			}
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < n; i++ {
+			v.Lookup(fmt.Sprintf("10.%d.%d.1", i/256, i%256))
+		}
+	}()
+
+	wg.Wait()
+}
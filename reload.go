@@ -0,0 +1,134 @@
+package view
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+)
+
+var errNoFileName = errors.New("view: no source file to watch")
+
+const (
+	// viewWatchPollInterval is how often Watch checks the source file's
+	// mtime for changes.
+	viewWatchPollInterval = time.Second
+
+	// viewWatchDebounce is how long Watch waits after seeing the mtime
+	// move before reloading, so a burst of writes from an editor's save
+	// (write, then chmod, then rename) collapses into a single reload.
+	viewWatchDebounce = 250 * time.Millisecond
+)
+
+// Reload re-parses fileName into a fresh table and atomically swaps it
+// in, so Lookup never observes a partially-loaded table. The new table
+// is fully parsed before anything is swapped: a malformed line leaves
+// the previous table intact and Reload returns the parse error. If
+// OnReload is set, it is called with the pre- and post-reload View on
+// success, or with new nil and err set on failure. Reload serializes
+// with Add, Remove and Replace (see mutate.go) via v.mu, so a reload
+// racing a bulk edit can't clobber it.
+func (v *View) Reload(fileName string) error {
+	root, lineNum, err := parseViewFile(fileName)
+	if err != nil {
+		if v.OnReload != nil {
+			v.OnReload(v, nil, err)
+		}
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	old := &View{
+		fileName:    v.fileName,
+		lineNum:     v.lineNum,
+		LastUpdated: v.LastUpdated,
+		ETag:        v.ETag,
+	}
+	old.root.Store(v.root.Load())
+
+	v.root.Store(root)
+	v.fileName = fileName
+	v.lineNum = lineNum
+	v.LastUpdated = time.Now()
+
+	if v.OnReload != nil {
+		v.OnReload(old, v, nil)
+	}
+
+	return nil
+}
+
+// Watch polls fileName (the file passed to Init or the most recent
+// Reload) for mtime changes and calls Reload whenever it changes,
+// debouncing rapid successive writes so an editor's save does not
+// trigger more than one reload. Watch blocks until ctx is canceled.
+//
+// This is a deliberate substitution for fsnotify-based watching: it costs
+// up to viewWatchPollInterval of reload latency instead of reacting
+// immediately, in exchange for no external dependency (there is no
+// go.mod in this tree to add one to). Swap it for an fsnotify-backed
+// implementation if that latency becomes a problem.
+func (v *View) Watch(ctx context.Context) error {
+	if v.fileName == "" {
+		return errNoFileName
+	}
+
+	lastMod, err := viewModTime(v.fileName)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(viewWatchPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			mod, err := viewModTime(v.fileName)
+			if err != nil || !mod.After(lastMod) {
+				continue
+			}
+
+			settled, err := viewWatchDebounced(ctx, v.fileName, mod)
+			if err != nil {
+				return err
+			}
+
+			lastMod = settled
+
+			v.Reload(v.fileName)
+		}
+	}
+}
+
+// viewWatchDebounced waits for fileName's mtime to stop moving before
+// returning, so a burst of saves collapses into the last one.
+func viewWatchDebounced(ctx context.Context, fileName string, mod time.Time) (time.Time, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return mod, ctx.Err()
+		case <-time.After(viewWatchDebounce):
+		}
+
+		cur, err := viewModTime(fileName)
+		if err != nil || cur.Equal(mod) {
+			return mod, nil
+		}
+
+		mod = cur
+	}
+}
+
+func viewModTime(fileName string) (time.Time, error) {
+	fi, err := os.Stat(fileName)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return fi.ModTime(), nil
+}
@@ -0,0 +1,328 @@
+package view
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net/netip"
+	"os"
+	"time"
+)
+
+var errBadMagic = errors.New("illegal snapshot magic")
+var errBadVersion = errors.New("unsupported snapshot version")
+var errCorruptSnapshot = errors.New("corrupt snapshot: crc32 mismatch")
+
+const (
+	snapshotMagic = "VSNP"
+
+	// snapshotVersion 2 added node.bit and the raw 16-byte key to the
+	// per-node encoding to support the path-compressed trie (see
+	// trie.go), where a node's depth no longer maps to a fixed bit
+	// position and its key can no longer be rebuilt from the path alone.
+	snapshotVersion = 2
+
+	// snapshotV4PrefixLen is the length of the all-zero leading run
+	// (snapshotV4Prefix) of the IPv4-mapped IPv6 prefix (::ffff:0:0/96)
+	// that viewKey anchors IPv4 keys under; the two 0xff bytes after it
+	// are checked separately. Used on load to tell an IPv4 entry apart
+	// from a native IPv6 one sharing the same bits.
+	snapshotV4PrefixLen = 10
+)
+
+var snapshotV4Prefix = [snapshotV4PrefixLen]byte{}
+
+// MarshalBinary serializes the view into a compact, versioned snapshot:
+// a 4-byte magic, a 1-byte version, a header (LastUpdated, ETag), a
+// varint-encoded area string table, a pre-order encoding of the trie
+// shape, and a trailing CRC32 over everything before it.
+func (v *View) MarshalBinary() ([]byte, error) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteString(snapshotMagic)
+	buf.WriteByte(snapshotVersion)
+
+	writeVarint(buf, v.LastUpdated.Unix())
+	writeString(buf, v.ETag)
+
+	areas := v.Areas()
+	areaIndex := make(map[string]int, len(areas))
+
+	writeUvarint(buf, uint64(len(areas)))
+	for i, area := range areas {
+		areaIndex[area] = i
+		writeString(buf, area)
+	}
+
+	snapshotEncodeNode(buf, v.root.Load(), areaIndex)
+
+	sum := crc32.ChecksumIEEE(buf.Bytes())
+
+	var trailer [4]byte
+	binary.BigEndian.PutUint32(trailer[:], sum)
+	buf.Write(trailer[:])
+
+	return buf.Bytes(), nil
+}
+
+// snapshotEncodeNode writes node and its children in pre-order, the same
+// order viewWalk visits them in: a flags byte (has-left, has-right,
+// has-info), node's own branch bit, and, for an info node, its area-table
+// index, prefix length and raw 16-byte key. The path-compressed trie (see
+// trie.go) can skip several bits between a node and its parent and a
+// node's route can be shorter than its branch bit, so both bit and key
+// must travel with the node instead of being rebuilt from its depth.
+func snapshotEncodeNode(buf *bytes.Buffer, node *viewNode, areaIndex map[string]int) {
+	var flags byte
+	if node.left != nil {
+		flags |= 1
+	}
+	if node.right != nil {
+		flags |= 2
+	}
+	if node.vinfo != nil {
+		flags |= 4
+	}
+
+	buf.WriteByte(flags)
+	writeUvarint(buf, uint64(node.bit))
+
+	if node.vinfo != nil {
+		writeUvarint(buf, uint64(areaIndex[node.vinfo.area]))
+		writeUvarint(buf, uint64(node.vbits))
+		buf.Write(node.key[:])
+	}
+
+	if node.left != nil {
+		snapshotEncodeNode(buf, node.left, areaIndex)
+	}
+	if node.right != nil {
+		snapshotEncodeNode(buf, node.right, areaIndex)
+	}
+}
+
+// UnmarshalBinary rebuilds the view directly from a snapshot produced by
+// MarshalBinary, without re-parsing or re-balancing: the trie shape is
+// read back node for node in the order it was written. It serializes
+// with Add, Remove, Replace and Reload via v.mu (see View.mu), so a
+// LoadSnapshot racing a bulk edit can't clobber it.
+func (v *View) UnmarshalBinary(data []byte) error {
+	if len(data) < len(snapshotMagic)+1+4 {
+		return errCorruptSnapshot
+	}
+
+	body, trailer := data[:len(data)-4], data[len(data)-4:]
+
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(trailer) {
+		return errCorruptSnapshot
+	}
+
+	r := bytes.NewReader(body)
+
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != snapshotMagic {
+		return errBadMagic
+	}
+
+	version, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if version != snapshotVersion {
+		return errBadVersion
+	}
+
+	lastUpdated, err := readVarint(r)
+	if err != nil {
+		return err
+	}
+
+	etag, err := readString(r)
+	if err != nil {
+		return err
+	}
+
+	areaCount, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+
+	areas := make([]string, areaCount)
+	for i := range areas {
+		areas[i], err = readString(r)
+		if err != nil {
+			return err
+		}
+	}
+
+	root := &viewNode{}
+
+	if err := snapshotDecodeNode(r, root, areas); err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.root.Store(root)
+	v.LastUpdated = time.Unix(lastUpdated, 0)
+	v.ETag = etag
+
+	return nil
+}
+
+// snapshotDecodeNode is the mirror of snapshotEncodeNode: it reads one
+// flags byte, node's branch bit, and, for an info node, its area, prefix
+// length and raw key, then recurses into its children. A non-info node's
+// key is not written (it is never consulted on its own, only compared
+// against), so after its children are decoded it is backfilled from
+// whichever child is present.
+func snapshotDecodeNode(r *bytes.Reader, node *viewNode, areas []string) error {
+	flags, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	bit, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	node.bit = int(bit)
+
+	if flags&4 != 0 {
+		areaIdx, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+		if int(areaIdx) >= len(areas) {
+			return errCorruptSnapshot
+		}
+
+		vbits, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+
+		var key [16]byte
+		if _, err := io.ReadFull(r, key[:]); err != nil {
+			return err
+		}
+
+		node.key = key
+		node.vbits = int(vbits)
+		node.vinfo = &ViewInfo{
+			area:   areas[areaIdx],
+			subnet: snapshotSubnetText(key, int(vbits)),
+			v4:     snapshotIsV4Key(key, int(vbits)),
+		}
+	}
+
+	if flags&1 != 0 {
+		node.left = &viewNode{}
+		if err := snapshotDecodeNode(r, node.left, areas); err != nil {
+			return err
+		}
+	}
+
+	if flags&2 != 0 {
+		node.right = &viewNode{}
+		if err := snapshotDecodeNode(r, node.right, areas); err != nil {
+			return err
+		}
+	}
+
+	if node.vinfo == nil {
+		switch {
+		case node.left != nil:
+			node.key = node.left.key
+		case node.right != nil:
+			node.key = node.right.key
+		}
+	}
+
+	return nil
+}
+
+// snapshotIsV4Key reports whether key/bits was produced by viewKey for an
+// IPv4 address: bits anchored at or past viewV4Offset and key carrying the
+// ::ffff:0:0/96 marker viewKey leaves the bytes under. It is how a loaded
+// snapshot recovers the address family ViewInfo.v4 needs, since the raw
+// key/bits pair is the only thing MarshalBinary persists per route.
+func snapshotIsV4Key(key [16]byte, bits int) bool {
+	return bits >= viewV4Offset && bytes.Equal(key[:snapshotV4PrefixLen], snapshotV4Prefix[:]) && key[10] == 0xff && key[11] == 0xff
+}
+
+// snapshotSubnetText rebuilds the canonical CIDR text for a trie key,
+// telling an IPv4 entry (anchored under ::ffff:0:0/96, see viewKey) apart
+// from a native IPv6 one landing on the same bits.
+func snapshotSubnetText(key [16]byte, bits int) string {
+	if snapshotIsV4Key(key, bits) {
+		var a4 [4]byte
+		copy(a4[:], key[12:16])
+
+		prefix := netip.PrefixFrom(netip.AddrFrom4(a4), bits-viewV4Offset)
+		return prefix.Masked().String()
+	}
+
+	prefix := netip.PrefixFrom(netip.AddrFrom16(key), bits)
+	return prefix.Masked().String()
+}
+
+func writeUvarint(buf *bytes.Buffer, x uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], x)
+	buf.Write(b[:n])
+}
+
+func writeVarint(buf *bytes.Buffer, x int64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(b[:], x)
+	buf.Write(b[:n])
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	writeUvarint(buf, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+func readVarint(r *bytes.Reader) (int64, error) {
+	return binary.ReadVarint(r)
+}
+
+func readString(r *bytes.Reader) (string, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// SaveSnapshot serializes the view and writes it to path.
+func (v *View) SaveSnapshot(path string) error {
+	data, err := v.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadSnapshot reads a snapshot previously written by SaveSnapshot and
+// rebuilds the view directly from it, skipping re-parsing and
+// re-balancing of the source view file.
+func (v *View) LoadSnapshot(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return v.UnmarshalBinary(data)
+}
@@ -0,0 +1,83 @@
+package view
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// TestSnapshotRoundTrip checks that MarshalBinary/UnmarshalBinary
+// preserve every route, area and header field across a save/load cycle,
+// and that LastUpdated survives the Unix-seconds truncation.
+func TestSnapshotRoundTrip(t *testing.T) {
+	v := New()
+
+	routes := map[string]string{
+		"10.0.0.0/8":      "A",
+		"10.1.0.0/16":     "B",
+		"2001:db8::/32":   "C",
+		"2001:db8:1::/48": "D",
+	}
+
+	for cidr, area := range routes {
+		if err := v.Add(cidr, area); err != nil {
+			t.Fatalf("Add(%s): %v", cidr, err)
+		}
+	}
+
+	v.LastUpdated = time.Unix(1700000000, 0)
+	v.ETag = "etag-1"
+
+	data, err := v.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	loaded := New()
+	if err := loaded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+
+	if !loaded.LastUpdated.Equal(v.LastUpdated) {
+		t.Errorf("LastUpdated = %v, want %v", loaded.LastUpdated, v.LastUpdated)
+	}
+	if loaded.ETag != v.ETag {
+		t.Errorf("ETag = %q, want %q", loaded.ETag, v.ETag)
+	}
+
+	for cidr, area := range routes {
+		prefix := netip.MustParsePrefix(cidr)
+
+		got := loaded.LookupAddr(prefix.Addr())
+		if got == nil || got.area != area {
+			t.Errorf("Lookup(%s) = %v, want area %s", prefix.Addr(), got, area)
+		}
+	}
+
+	gotNets, gotAreas := loaded.Count()
+	wantNets, wantAreas := v.Count()
+	if gotNets != wantNets || gotAreas != wantAreas {
+		t.Errorf("Count() = (%d, %d), want (%d, %d)", gotNets, gotAreas, wantNets, wantAreas)
+	}
+}
+
+// TestSnapshotRoundTripCorrupt checks that a flipped byte in the middle
+// of the snapshot is caught by the CRC32 check instead of silently
+// loading garbage.
+func TestSnapshotRoundTripCorrupt(t *testing.T) {
+	v := New()
+	if err := v.Add("10.0.0.0/8", "A"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := v.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	data[len(data)/2] ^= 0xff
+
+	if err := New().UnmarshalBinary(data); err != errCorruptSnapshot {
+		t.Errorf("UnmarshalBinary(corrupt) = %v, want errCorruptSnapshot", err)
+	}
+}
@@ -0,0 +1,187 @@
+package view
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Source yields (area, cidrs) records for InitFromSource to load. Next
+// returns io.EOF once the underlying input is exhausted.
+type Source interface {
+	Next() (area string, cidrs []string, err error)
+}
+
+// InitFromSource loads the view from src instead of a BIND-style view
+// file, so callers can feed it GeoIP exports, routing table dumps, or
+// spreadsheets via a Source implementation without preprocessing them
+// into the on-disk format Init expects. Like Init, the new table is
+// built in full before being published.
+func (v *View) InitFromSource(src Source) (err error) {
+	root := &viewNode{bit: viewMaxBits}
+
+	var n uint
+
+	for {
+		area, cidrs, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		n++
+
+		for _, cidr := range cidrs {
+			var serr error
+
+			root, serr = viewSubnet(root, []byte(cidr), []byte(area))
+			if serr != nil {
+				return fmt.Errorf("%s Record:%d", serr.Error(), n)
+			}
+		}
+	}
+
+	v.fileName = ""
+	v.lineNum = n
+	v.root.Store(root)
+
+	return nil
+}
+
+// bindSource is the current BIND ACL-like format (`key area match-type {
+// {subnet1;subnet2;};};`), one record per non-empty line.
+type bindSource struct {
+	scanner *bufio.Scanner
+	lineNum uint
+}
+
+// NewBindSource returns a Source reading the BIND ACL-like format that
+// Init parses from a file, letting it be loaded from any io.Reader.
+func NewBindSource(r io.Reader) Source {
+	return &bindSource{scanner: bufio.NewScanner(r)}
+}
+
+func (s *bindSource) Next() (area string, cidrs []string, err error) {
+	for s.scanner.Scan() {
+		s.lineNum++
+
+		line := bytes.TrimSpace(s.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		area, cidrs, err = parseBindLine(line)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s Line:%d", err.Error(), s.lineNum)
+		}
+
+		return area, cidrs, nil
+	}
+
+	if err := s.scanner.Err(); err != nil {
+		return "", nil, err
+	}
+
+	return "", nil, io.EOF
+}
+
+// csvSource is a line-oriented `cidr,area` format, one record per row.
+type csvSource struct {
+	reader *csv.Reader
+}
+
+// NewCSVSource returns a Source reading `cidr,area` rows.
+func NewCSVSource(r io.Reader) Source {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 2
+
+	return &csvSource{reader: cr}
+}
+
+func (s *csvSource) Next() (area string, cidrs []string, err error) {
+	record, err := s.reader.Read()
+	if err != nil {
+		return "", nil, err
+	}
+
+	return strings.TrimSpace(record[1]), []string{strings.TrimSpace(record[0])}, nil
+}
+
+// jsonRecord is the shape jsonSource expects from each line of input.
+type jsonRecord struct {
+	Area string `json:"area"`
+	CIDR string `json:"cidr"`
+}
+
+// jsonSource is newline-delimited JSON, one `{"area":"...","cidr":"..."}`
+// record per line.
+type jsonSource struct {
+	scanner *bufio.Scanner
+}
+
+// NewJSONSource returns a Source reading newline-delimited JSON records
+// of the form {"area":"...","cidr":"..."}.
+func NewJSONSource(r io.Reader) Source {
+	return &jsonSource{scanner: bufio.NewScanner(r)}
+}
+
+func (s *jsonSource) Next() (area string, cidrs []string, err error) {
+	for s.scanner.Scan() {
+		line := bytes.TrimSpace(s.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var rec jsonRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return "", nil, err
+		}
+
+		return rec.Area, []string{rec.CIDR}, nil
+	}
+
+	if err := s.scanner.Err(); err != nil {
+		return "", nil, err
+	}
+
+	return "", nil, io.EOF
+}
+
+// plainSource is a whitespace-separated `cidr area` format, one record
+// per non-empty line.
+type plainSource struct {
+	scanner *bufio.Scanner
+}
+
+// NewPlainSource returns a Source reading `cidr area` lines.
+func NewPlainSource(r io.Reader) Source {
+	return &plainSource{scanner: bufio.NewScanner(r)}
+}
+
+func (s *plainSource) Next() (area string, cidrs []string, err error) {
+	for s.scanner.Scan() {
+		line := bytes.TrimSpace(s.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		fields := bytes.Fields(line)
+		if len(fields) != 2 {
+			return "", nil, fmt.Errorf("%s: %q", errBadLine.Error(), line)
+		}
+
+		return string(fields[1]), []string{string(fields[0])}, nil
+	}
+
+	if err := s.scanner.Err(); err != nil {
+		return "", nil, err
+	}
+
+	return "", nil, io.EOF
+}
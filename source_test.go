@@ -0,0 +1,146 @@
+package view
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// drainSource reads src to io.EOF, collecting every (cidr, area) pair
+// across however many cidrs each record yields.
+func drainSource(t *testing.T, src Source) []lpmRoute {
+	t.Helper()
+
+	var got []lpmRoute
+
+	for {
+		area, cidrs, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+
+		for _, cidr := range cidrs {
+			got = append(got, lpmRoute{cidr: cidr, area: area})
+		}
+	}
+
+	return got
+}
+
+func TestBindSource(t *testing.T) {
+	const input = `key A type { {10.0.0.0/8;10.1.0.0/16;};};
+key B type { {2001:db8::/32;};};
+`
+
+	got := drainSource(t, NewBindSource(strings.NewReader(input)))
+	want := []lpmRoute{
+		{cidr: "10.0.0.0/8", area: "A"},
+		{cidr: "10.1.0.0/16", area: "A"},
+		{cidr: "2001:db8::/32", area: "B"},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("record %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestBindSourceBadLine(t *testing.T) {
+	src := NewBindSource(strings.NewReader("not a bind line\n"))
+
+	if _, _, err := src.Next(); err == nil {
+		t.Error("Next() = nil error, want a parse error")
+	}
+}
+
+func TestCSVSource(t *testing.T) {
+	const input = "10.0.0.0/8,A\n2001:db8::/32,B\n"
+
+	got := drainSource(t, NewCSVSource(strings.NewReader(input)))
+	want := []lpmRoute{
+		{cidr: "10.0.0.0/8", area: "A"},
+		{cidr: "2001:db8::/32", area: "B"},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("record %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestJSONSource(t *testing.T) {
+	const input = `{"area":"A","cidr":"10.0.0.0/8"}
+{"area":"B","cidr":"2001:db8::/32"}
+`
+
+	got := drainSource(t, NewJSONSource(strings.NewReader(input)))
+	want := []lpmRoute{
+		{cidr: "10.0.0.0/8", area: "A"},
+		{cidr: "2001:db8::/32", area: "B"},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("record %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestPlainSource(t *testing.T) {
+	const input = "10.0.0.0/8 A\n2001:db8::/32 B\n"
+
+	got := drainSource(t, NewPlainSource(strings.NewReader(input)))
+	want := []lpmRoute{
+		{cidr: "10.0.0.0/8", area: "A"},
+		{cidr: "2001:db8::/32", area: "B"},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("record %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestPlainSourceBadLine(t *testing.T) {
+	src := NewPlainSource(strings.NewReader("10.0.0.0/8\n"))
+
+	if _, _, err := src.Next(); err == nil {
+		t.Error("Next() = nil error, want a field-count error")
+	}
+}
+
+// TestInitFromSource checks that InitFromSource loads every record a
+// Source yields into the resulting View.
+func TestInitFromSource(t *testing.T) {
+	v := New()
+
+	err := v.InitFromSource(NewPlainSource(strings.NewReader("10.0.0.0/8 A\n2001:db8::/32 B\n")))
+	if err != nil {
+		t.Fatalf("InitFromSource: %v", err)
+	}
+
+	if got, _ := v.Lookup("10.1.2.3"); got == nil || got.area != "A" {
+		t.Errorf("Lookup(10.1.2.3) = %v, want area A", got)
+	}
+	if got, _ := v.Lookup("2001:db8::1"); got == nil || got.area != "B" {
+		t.Errorf("Lookup(2001:db8::1) = %v, want area B", got)
+	}
+}
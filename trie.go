@@ -0,0 +1,316 @@
+package view
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// viewNode is a node in a path-compressed (Patricia-style) binary trie.
+// bit is the position of the next bit examined to choose between left
+// and right; runs of the uncompressed bit-at-a-time trie that have no
+// branching and no info are elided, so descending from one node to the
+// next can skip many bits at once. key is a representative key for the
+// subtree — any inserted key agreeing with it on [0, bit) works — used
+// to verify the skipped bits during insert and lookup.
+//
+// A node with vinfo set holds a route whose prefix is vbits bits long,
+// which may be shorter than bit: a supernet route can sit above a more
+// specific one without interrupting the compressed path to it.
+type viewNode struct {
+	left  *viewNode
+	right *viewNode
+	vinfo *ViewInfo
+	vbits int
+
+	key [16]byte
+	bit int
+}
+
+// viewBit returns the bit at position pos (0 is the most significant bit)
+// of a 128-bit key.
+func viewBit(key [16]byte, pos int) byte {
+	return (key[pos>>3] >> (7 - uint(pos&7))) & 1
+}
+
+// viewMaskKey zeroes every bit of key from position bits onward, so two
+// keys that only disagree outside their shared prefix length still
+// compare equal.
+func viewMaskKey(key [16]byte, bits int) [16]byte {
+	for i := 0; i < len(key); i++ {
+		byteStart := i * 8
+
+		switch {
+		case byteStart+8 <= bits:
+			// fully inside the prefix, keep as-is
+		case byteStart >= bits:
+			key[i] = 0
+		default:
+			keep := bits - byteStart
+			key[i] &= 0xFF << uint(8-keep)
+		}
+	}
+
+	return key
+}
+
+func viewKeyHalves(key [16]byte) (hi, lo uint64) {
+	return binary.BigEndian.Uint64(key[0:8]), binary.BigEndian.Uint64(key[8:16])
+}
+
+// firstDiffBit returns the position of the first bit at which a and b
+// disagree, or viewMaxBits if they are identical.
+func firstDiffBit(a, b [16]byte) int {
+	ah, al := viewKeyHalves(a)
+	bh, bl := viewKeyHalves(b)
+
+	if xh := ah ^ bh; xh != 0 {
+		return bits.LeadingZeros64(xh)
+	}
+
+	if xl := al ^ bl; xl != 0 {
+		return 64 + bits.LeadingZeros64(xl)
+	}
+
+	return viewMaxBits
+}
+
+// viewInsert inserts info under key/bits into the tree rooted at node,
+// returning the (possibly new) subtree root. It takes an explicit root,
+// rather than a *View, so a new table can be built off to the side (see
+// parseViewFile) and only published once it is known to be complete.
+//
+// It never mutates a node it didn't just allocate: every node on the path
+// from node down to the insertion point is shallow-copied before being
+// changed, and only the clone is linked into the result. node itself, and
+// everything reachable from it, is left exactly as a caller found it —
+// which is what lets Add (see mutate.go) run this against a live,
+// already-published root while Lookup concurrently walks the old one.
+func viewInsert(node *viewNode, key [16]byte, bits int, info *ViewInfo) (*viewNode, error) {
+	key = viewMaskKey(key, bits)
+
+	if node == nil {
+		return &viewNode{key: key, bit: bits, vinfo: info, vbits: bits}, nil
+	}
+
+	if node.vinfo == nil && node.left == nil && node.right == nil {
+		return &viewNode{key: key, bit: bits, vinfo: info, vbits: bits}, nil
+	}
+
+	diff := firstDiffBit(key, node.key)
+
+	limit := node.bit
+	branch := diff
+	if limit < branch {
+		branch = limit
+	}
+
+	if bits <= branch {
+		if bits == branch && branch == limit {
+			// The new prefix lands exactly on node's own position.
+			if node.vinfo != nil {
+				return node, ErrDuplicate
+			}
+
+			clone := *node
+			clone.vinfo = info
+			clone.vbits = bits
+
+			return &clone, nil
+		}
+
+		// Shorter than the region key and node.key are known to agree
+		// on: the new prefix becomes a new ancestor of node.
+		parent := &viewNode{key: key, bit: bits, vinfo: info, vbits: bits}
+		if viewBit(node.key, bits) == 0 {
+			parent.left = node
+		} else {
+			parent.right = node
+		}
+
+		return parent, nil
+	}
+
+	if diff < limit {
+		// key and node.key genuinely diverge inside the region both
+		// prefixes claim, before node's own branch bit: split the edge
+		// above node into a new branch node. (diff == limit is not
+		// this case: the keys first disagree exactly on node's own
+		// branch bit, which node is already equipped to decide on, so
+		// it falls through to the plain descend below instead.)
+		leaf := &viewNode{key: key, bit: bits, vinfo: info, vbits: bits}
+		split := &viewNode{key: key, bit: diff}
+
+		demoted := node
+		if node.vinfo != nil && node.vbits <= branch {
+			// node's own route is no longer than the split point, so it
+			// still covers every address on both sides of the split:
+			// promote it onto the new branch node instead of stranding
+			// it on the demoted leaf, where it would stop matching
+			// addresses that fall on the other side.
+			split.vinfo, split.vbits = node.vinfo, node.vbits
+
+			clone := *node
+			clone.vinfo, clone.vbits = nil, 0
+			demoted = viewCompact(&clone)
+		}
+
+		if viewBit(key, diff) == 0 {
+			split.left, split.right = leaf, demoted
+		} else {
+			split.left, split.right = demoted, leaf
+		}
+
+		return split, nil
+	}
+
+	// branch == limit < bits: key agrees with node.key through node's own
+	// branch bit, so descend into the matching child.
+	clone := *node
+	var err error
+	if viewBit(key, limit) == 0 {
+		clone.left, err = viewInsert(node.left, key, bits, info)
+	} else {
+		clone.right, err = viewInsert(node.right, key, bits, info)
+	}
+
+	return &clone, err
+}
+
+// viewRemove removes the route registered under exactly key/bits from the
+// tree rooted at node, returning the (possibly new, possibly nil) subtree
+// root and whether a route was actually removed. It mirrors viewInsert's
+// branch/diff/limit case analysis to find the node holding that exact
+// route, then compacts the path back up with viewCompact so removal never
+// leaves dangling interior nodes behind.
+//
+// Like viewInsert, it never mutates a node in place: every node on the
+// path to the removed route is shallow-copied before its vinfo or
+// children are changed, so Remove (see mutate.go) can run this against a
+// live root while Lookup concurrently walks the old one.
+func viewRemove(node *viewNode, key [16]byte, bits int) (*viewNode, bool) {
+	if node == nil {
+		return nil, false
+	}
+
+	key = viewMaskKey(key, bits)
+
+	if node.vinfo == nil && node.left == nil && node.right == nil {
+		return node, false
+	}
+
+	diff := firstDiffBit(key, node.key)
+
+	limit := node.bit
+	branch := diff
+	if limit < branch {
+		branch = limit
+	}
+
+	if bits <= branch {
+		if bits == branch && branch == limit && node.vinfo != nil && node.vbits == bits {
+			clone := *node
+			clone.vinfo = nil
+			clone.vbits = 0
+
+			return viewCompact(&clone), true
+		}
+
+		return node, false
+	}
+
+	if diff < limit {
+		// key diverges from node.key before reaching node: no such route.
+		return node, false
+	}
+
+	// branch == limit < bits: key agrees with node.key through node's own
+	// branch bit, so descend into the matching child.
+	child := node.left
+	if viewBit(key, limit) != 0 {
+		child = node.right
+	}
+
+	newChild, removed := viewRemove(child, key, bits)
+	if !removed {
+		return node, false
+	}
+
+	clone := *node
+	if viewBit(key, limit) != 0 {
+		clone.right = newChild
+	} else {
+		clone.left = newChild
+	}
+
+	return viewCompact(&clone), true
+}
+
+// viewCompact drops node if it has become a useless pass-through: a node
+// holding no route with zero children collapses to nil, and one with no
+// route and a single child is replaced by that child, keeping the trie's
+// path compression intact after a removal.
+func viewCompact(node *viewNode) *viewNode {
+	if node.vinfo != nil {
+		return node
+	}
+
+	switch {
+	case node.left == nil && node.right == nil:
+		return nil
+	case node.left == nil:
+		return node.right
+	case node.right == nil:
+		return node.left
+	default:
+		return node
+	}
+}
+
+// viewLookup walks node for the longest prefix matching key, skipping
+// directly from branch point to branch point instead of testing one bit
+// at a time. queryV4 is the address family of key (see ViewInfo.v4): a
+// route registered under the other family is never credited, even if its
+// bits happen to fall within key's range, so a short or default IPv6
+// route can't shadow an IPv4 query (or vice versa).
+func viewLookup(node *viewNode, key [16]byte, queryV4 bool) (info *ViewInfo) {
+	for node != nil {
+		diff := firstDiffBit(key, node.key)
+
+		if node.vinfo != nil && diff >= node.vbits && node.vinfo.v4 == queryV4 {
+			info = node.vinfo
+		}
+
+		if node.bit >= viewMaxBits || diff < node.bit {
+			break
+		}
+
+		if viewBit(key, node.bit) != 0 {
+			node = node.right
+		} else {
+			node = node.left
+		}
+	}
+
+	return
+}
+
+// viewWalk visits node and its children in sorted CIDR order (left/0
+// before right/1), invoking fn for every vinfo encountered. It stops and
+// returns false as soon as fn returns false.
+func viewWalk(node *viewNode, fn func(*ViewInfo) bool) bool {
+	if node == nil {
+		return true
+	}
+
+	if node.vinfo != nil {
+		if !fn(node.vinfo) {
+			return false
+		}
+	}
+
+	if !viewWalk(node.left, fn) {
+		return false
+	}
+
+	return viewWalk(node.right, fn)
+}
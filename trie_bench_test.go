@@ -0,0 +1,77 @@
+package view
+
+import (
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+// buildBenchTrie inserts n distinct /24 IPv4 subnets, deterministically
+// generated from seed, into a fresh trie and returns its root along with
+// one address known to fall inside each inserted subnet.
+func buildBenchTrie(n int, seed int64) (root *viewNode, addrs []netip.Addr) {
+	rng := rand.New(rand.NewSource(seed))
+
+	root = &viewNode{bit: viewMaxBits}
+	addrs = make([]netip.Addr, 0, n)
+
+	seen := make(map[uint32]bool, n)
+
+	for len(addrs) < n {
+		network := rng.Uint32() &^ 0xff
+
+		if seen[network] {
+			continue
+		}
+		seen[network] = true
+
+		a4 := [4]byte{byte(network >> 24), byte(network >> 16), byte(network >> 8), byte(network)}
+		addr := netip.AddrFrom4(a4)
+
+		key, bits := viewKey(addr, 24)
+
+		var err error
+		root, err = viewInsert(root, key, bits, &ViewInfo{
+			area:   fmt.Sprintf("area-%d", len(addrs)%64),
+			subnet: netip.PrefixFrom(addr, 24).String(),
+			v4:     true,
+		})
+		if err != nil {
+			continue
+		}
+
+		addrs = append(addrs, netip.AddrFrom4([4]byte{a4[0], a4[1], a4[2], byte(rng.Intn(256))}))
+	}
+
+	return root, addrs
+}
+
+// BenchmarkLookup measures lookup throughput and per-call allocations
+// against a realistic 500k-prefix table, the scale the path-compressed
+// trie (see trie.go) targets over the original bit-at-a-time one.
+func BenchmarkLookup(b *testing.B) {
+	const n = 500_000
+
+	root, addrs := buildBenchTrie(n, 1)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		key, _ := viewKey(addrs[i%len(addrs)], 0)
+		viewLookup(root, key, true)
+	}
+}
+
+// BenchmarkInsert measures build throughput for the same 500k-prefix
+// table, inserted from an empty trie.
+func BenchmarkInsert(b *testing.B) {
+	const n = 500_000
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		buildBenchTrie(n, int64(i))
+	}
+}
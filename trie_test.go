@@ -0,0 +1,155 @@
+package view
+
+import (
+	"net/netip"
+	"testing"
+)
+
+// lpmRoute is one entry in a longest-prefix-match table used to exercise
+// viewInsert/viewLookup directly, independent of insertion order.
+type lpmRoute struct {
+	cidr string
+	area string
+}
+
+// buildTrie inserts routes, in the order given, into a fresh trie.
+func buildTrie(t *testing.T, routes []lpmRoute) *viewNode {
+	t.Helper()
+
+	root := &viewNode{bit: viewMaxBits}
+
+	for _, r := range routes {
+		prefix := netip.MustParsePrefix(r.cidr)
+		key, bits := viewKey(prefix.Addr(), prefix.Bits())
+
+		newRoot, err := viewInsert(root, key, bits, &ViewInfo{area: r.area, subnet: r.cidr, v4: prefix.Addr().Is4()})
+		if err != nil {
+			t.Fatalf("insert %s: %v", r.cidr, err)
+		}
+		root = newRoot
+	}
+
+	return root
+}
+
+// TestViewLookupOverlappingPrefixes checks that viewLookup returns the
+// longest matching route among several overlapping prefixes, regardless
+// of the order they were inserted in: a supernet route must stay
+// reachable for addresses outside its more specific children, and the
+// most specific match must still win where they overlap.
+func TestViewLookupOverlappingPrefixes(t *testing.T) {
+	routes := []lpmRoute{
+		{"10.0.0.0/8", "A"},
+		{"10.1.0.0/16", "B"},
+		{"10.1.2.0/24", "C"},
+		{"2001:db8::/32", "D"},
+		{"2001:db8:1::/48", "E"},
+	}
+
+	wants := []struct {
+		addr string
+		area string
+	}{
+		{"10.2.3.4", "A"}, // under /8 only
+		{"10.1.5.6", "B"}, // under /8 and /16
+		{"10.1.2.7", "C"}, // under /8, /16 and /24
+		{"2001:db8:2::1", "D"},
+		{"2001:db8:1::1", "E"},
+	}
+
+	orders := [][]int{
+		{0, 1, 2, 3, 4},
+		{4, 3, 2, 1, 0},
+		{2, 0, 4, 1, 3},
+	}
+
+	for _, order := range orders {
+		reordered := make([]lpmRoute, len(routes))
+		for i, idx := range order {
+			reordered[i] = routes[idx]
+		}
+
+		root := buildTrie(t, reordered)
+
+		for _, w := range wants {
+			addr := netip.MustParseAddr(w.addr)
+			key, _ := viewKey(addr, 0)
+
+			got := viewLookup(root, key, addr.Is4())
+			if got == nil || got.area != w.area {
+				t.Errorf("order %v: Lookup(%s) = %v, want area %s", order, w.addr, got, w.area)
+			}
+		}
+	}
+}
+
+// TestViewInsertDuplicate checks that re-inserting an already-registered
+// subnet is rejected with ErrDuplicate instead of creating a second node
+// for it, for both a non-host and a host route.
+func TestViewInsertDuplicate(t *testing.T) {
+	for _, cidr := range []string{"10.0.0.0/24", "10.0.0.1/32"} {
+		prefix := netip.MustParsePrefix(cidr)
+		key, bits := viewKey(prefix.Addr(), prefix.Bits())
+
+		root := &viewNode{bit: viewMaxBits}
+
+		root, err := viewInsert(root, key, bits, &ViewInfo{area: "a"})
+		if err != nil {
+			t.Fatalf("%s: first insert: %v", cidr, err)
+		}
+
+		if _, err := viewInsert(root, key, bits, &ViewInfo{area: "b"}); err != ErrDuplicate {
+			t.Errorf("%s: second insert = %v, want ErrDuplicate", cidr, err)
+		}
+	}
+}
+
+// TestViewLookupAddressFamily checks that a short or default IPv6 route
+// never shadows an IPv4 query, and vice versa: viewKey only separates the
+// two families by prefix length (see viewV4Offset), so a route like
+// ::/1 sits near the trie root and, without an explicit family check in
+// viewLookup, would otherwise match every IPv4-mapped key too.
+func TestViewLookupAddressFamily(t *testing.T) {
+	routes := []lpmRoute{
+		{"::/1", "v6-default"},
+		{"0.0.0.0/0", "v4-default"},
+		{"10.0.0.0/8", "v4-more-specific"},
+		{"2001:db8::/32", "v6-more-specific"},
+	}
+
+	root := buildTrie(t, routes)
+
+	wants := []struct {
+		addr string
+		area string
+	}{
+		{"8.8.8.8", "v4-default"},
+		{"10.1.2.3", "v4-more-specific"},
+		{"::1", "v6-default"},
+		{"2001:db8::1", "v6-more-specific"},
+	}
+
+	for _, w := range wants {
+		addr := netip.MustParseAddr(w.addr)
+		key, _ := viewKey(addr, 0)
+
+		got := viewLookup(root, key, addr.Is4())
+		if got == nil || got.area != w.area {
+			t.Errorf("Lookup(%s) = %v, want area %s", w.addr, got, w.area)
+		}
+	}
+}
+
+// TestViewLookupAddressFamilyNoV4Route checks that an IPv4 query finds
+// nothing when only a short native-IPv6 route is registered, instead of
+// falling back to it.
+func TestViewLookupAddressFamilyNoV4Route(t *testing.T) {
+	root := buildTrie(t, []lpmRoute{{"::/1", "v6-default"}})
+
+	addr := netip.MustParseAddr("8.8.8.8")
+	key, _ := viewKey(addr, 0)
+
+	if got := viewLookup(root, key, addr.Is4()); got != nil {
+		t.Errorf("Lookup(8.8.8.8) = %v, want nil", got)
+	}
+}
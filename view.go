@@ -3,21 +3,28 @@ package view
 import (
 	"bufio"
 	"bytes"
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"net/netip"
 	"os"
-	"strconv"
-	"unsafe"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var errBadLine = errors.New("illegal line format")
 var errBadSubnet = errors.New("illegal subnet format")
-var errDupNet = errors.New("duplication subnet not allowed")
 var errBadAddress = errors.New("illegal address format")
 
-var bigEndian bool
+// ErrNotFound is returned when a lookup by area or subnet matches nothing,
+// so callers can branch on it instead of string-matching an error.
+var ErrNotFound = errors.New("view: not found")
+
+// ErrDuplicate is returned by Add when the exact subnet is already
+// registered, so callers can branch on it instead of string-matching an
+// error.
+var ErrDuplicate = errors.New("view: duplicate subnet")
 
 const (
 	viewLineDelimite = " "
@@ -28,144 +35,103 @@ const (
 	viewNetFieldSuffix = ";};};"
 	viewNetFieldSep    = ";"
 
-	viewSubnetDelimite = "/"
+	// viewV4Offset is the bit offset at which an IPv4 prefix is anchored
+	// inside the 128-bit key space, below the IPv4-mapped IPv6 prefix
+	// (::ffff:0:0/96). This separates the families by prefix length only
+	// (see ViewInfo.v4).
+	viewV4Offset = 96
 
-	viewIpaddrDelimite = "."
+	// viewMaxBits is the width of the key space: every address, v4 or
+	// v6, is walked as a 128-bit key.
+	viewMaxBits = 128
 )
 
 type ViewInfo struct {
 	area   string
 	subnet string
-}
 
-type viewNode struct {
-	left  *viewNode
-	right *viewNode
-	vinfo *ViewInfo
+	// v4 records the address family the route was registered under,
+	// taken from netip.Addr.Is4() at insert time. viewLookup uses it to
+	// keep a native IPv6 route from matching a v4 query (or vice versa):
+	// viewKey's +viewV4Offset anchoring only separates the two families
+	// by prefix length, and a short/default IPv6 route (e.g. ::/1) can
+	// still land within an IPv4-mapped key's range.
+	v4 bool
 }
 
 type View struct {
-	root     viewNode
+	root     atomic.Pointer[viewNode]
 	fileName string
 	lineNum  uint
-}
 
-func init() {
-	var i int32 = 0x01020304
-
-	u := unsafe.Pointer(&i)
-	pb := (*byte)(u)
-
-	b := *pb
-	if b == 0x01 {
-		bigEndian = true
-	}
-}
-
-func viewUintAddr(netaddr [4]uint8) (unetaddr uint32) {
-	buf := bytes.NewReader(netaddr[:])
-
-	binary.Read(buf, binary.BigEndian, &unetaddr)
-
-	return
+	// mu serializes writers (Add, Remove, Replace, Reload, UnmarshalBinary):
+	// each reads the current root, builds its replacement, and stores it
+	// back, and two writers racing that read-modify-store would step on
+	// each other. Lookup only ever loads root, never takes mu, so it
+	// stays lock-free and safe to call from any number of goroutines
+	// regardless of concurrent writers.
+	mu sync.Mutex
+
+	// LastUpdated and ETag let callers key a snapshot (see
+	// MarshalBinary) as a cache against the freshness of the source
+	// view file; Init does not set them, callers populate them from
+	// their own source (file mtime, HTTP ETag, ...).
+	LastUpdated time.Time
+	ETag        string
+
+	// OnReload, if set, is invoked after every Reload (see reload.go)
+	// with the pre- and post-reload View, or with new nil and err set
+	// when the reload was rejected.
+	OnReload func(old, new *View, err error)
 }
 
 func New() *View {
-	return &View{}
-}
-
-func (v *View) viewInsert(unetaddr uint32, mask int, info *ViewInfo) (err error) {
-	var bit uint32 = 0x80000000
-
-	node := &v.root
-	next := &v.root
-
-	for mask > 0 {
-		if unetaddr&bit != 0 {
-			next = node.right
-		} else {
-			next = node.left
-		}
-
-		if next == nil {
-			break
-		}
-
-		bit >>= 1
-
-		node = next
-
-		mask--
-	}
-
-	if next != nil {
-		if node.vinfo != nil {
-			return errDupNet
-		}
-
-		node.vinfo = info
-		return nil
-	}
-
-	for mask > 0 {
-		next = &viewNode{}
-
-		if unetaddr&bit != 0 {
-			node.right = next
-		} else {
-			node.left = next
-		}
-
-		mask--
-		bit >>= 1
-		node = next
-	}
-
-	node.vinfo = info
+	v := &View{}
+	v.root.Store(&viewNode{bit: viewMaxBits})
 
-	return nil
+	return v
 }
 
-func (v *View) viewSubnet(subnet []byte, area []byte) (err error) {
-	var (
-		netaddr [4]uint8
-		mask    int
-	)
-
-	netfs := bytes.Split(subnet, []byte(viewSubnetDelimite))
-	if len(netfs) != 2 {
-		return errBadSubnet
-	}
-
-	ipfs := bytes.Split(netfs[0], []byte(viewIpaddrDelimite))
-	if len(ipfs) != 4 {
-		return errBadSubnet
+// viewKey folds addr into the 128-bit key space and returns the number of
+// significant bits for a prefix of length prefixLen. IPv4 addresses are
+// anchored at viewV4Offset, below ::ffff:0:0/96, so an IPv4 key is the
+// same as the key of its IPv4-mapped IPv6 form, and a short IPv6 route
+// can cover IPv4 keys; ViewInfo.v4 keeps the families apart on lookup.
+func viewKey(addr netip.Addr, prefixLen int) (key [16]byte, bits int) {
+	key = addr.As16()
+	bits = prefixLen
+
+	if addr.Is4() {
+		bits += viewV4Offset
 	}
 
-	for i := 0; i < 4; i++ {
-		baddr, err := strconv.Atoi(string(ipfs[i]))
-		if err != nil {
-			return errBadSubnet
-		}
-		netaddr[i] = uint8(baddr)
-	}
+	return
+}
 
-	mask, err = strconv.Atoi(string(netfs[1]))
+// viewSubnet inserts subnet/area into the tree rooted at root, returning
+// the (possibly new) root. A duplicate subnet is silently ignored, same
+// as the original bit-at-a-time trie did.
+func viewSubnet(root *viewNode, subnet []byte, area []byte) (*viewNode, error) {
+	prefix, err := netip.ParsePrefix(string(subnet))
 	if err != nil {
-		return errBadSubnet
+		return root, errBadSubnet
 	}
 
-	unetaddr := viewUintAddr(netaddr)
+	key, bits := viewKey(prefix.Addr(), prefix.Bits())
 
-	v.viewInsert(unetaddr, mask, &ViewInfo{area: string(area), subnet: string(subnet)})
+	newRoot, _ := viewInsert(root, key, bits, &ViewInfo{area: string(area), subnet: string(subnet), v4: prefix.Addr().Is4()})
 
-	return nil
+	return newRoot, nil
 }
 
-func (v *View) viewLine(line []byte) (err error) {
+// parseBindLine splits a single line of the BIND ACL-like format (`key
+// area match-type { {subnet1;subnet2;};};`) into its area and subnets,
+// without touching any trie. It is shared by viewLine and bindSource so
+// the two stay in lockstep.
+func parseBindLine(line []byte) (area string, cidrs []string, err error) {
 	fields := bytes.Fields(line)
 	if len(fields) != viewLineFields {
-		return fmt.Errorf("%s Line:%d\n", errBadLine.Error(), v.lineNum)
+		return "", nil, errBadLine
 	}
 
 	subNetBuf := fields[viewLineNetField]
@@ -178,28 +144,48 @@ func (v *View) viewLine(line []byte) (err error) {
 	}
 
 	subNets := bytes.Split(subNetBuf, []byte(viewNetFieldSep))
-	if len(subNets) < 1 {
-		return
+
+	cidrs = make([]string, 0, len(subNets))
+	for _, s := range subNets {
+		if len(s) == 0 {
+			continue
+		}
+		cidrs = append(cidrs, string(s))
+	}
+
+	return string(fields[1]), cidrs, nil
+}
+
+func viewLine(root *viewNode, line []byte, lineNum uint) (newRoot *viewNode, err error) {
+	area, cidrs, err := parseBindLine(line)
+	if err != nil {
+		return root, fmt.Errorf("%s Line:%d\n", err.Error(), lineNum)
 	}
 
-	for i := 0; i < len(subNets); i++ {
-		if err = v.viewSubnet(subNets[i], fields[1]); err != nil {
-			return fmt.Errorf("%s Line:%d\n", err.Error(), v.lineNum)
+	for _, cidr := range cidrs {
+		var serr error
+
+		root, serr = viewSubnet(root, []byte(cidr), []byte(area))
+		if serr != nil {
+			return root, fmt.Errorf("%s Line:%d\n", serr.Error(), lineNum)
 		}
 	}
 
-	return nil
+	return root, nil
 }
 
-func (v *View) Init(fileName string) (err error) {
+// parseViewFile parses fileName into a freestanding trie, unattached to
+// any View, so the caller can validate it fully before publishing it —
+// Init and Reload (see reload.go) both publish it only on success.
+func parseViewFile(fileName string) (root *viewNode, lineNum uint, err error) {
 	file, err := os.Open(fileName)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
 
 	defer file.Close()
 
-	v.fileName = fileName
+	root = &viewNode{bit: viewMaxBits}
 
 	reader := bufio.NewReader(file)
 
@@ -207,12 +193,12 @@ func (v *View) Init(fileName string) (err error) {
 	for err == nil {
 		line, err = reader.ReadBytes('\n')
 		if err != nil && err != io.EOF {
-			return err
+			return nil, lineNum, err
 		}
 
 		line = bytes.TrimSpace(line)
 
-		v.lineNum++
+		lineNum++
 
 		if len(line) == 0 {
 			if err == io.EOF {
@@ -221,8 +207,11 @@ func (v *View) Init(fileName string) (err error) {
 			continue
 		}
 
-		if lerr := v.viewLine(line); lerr != nil {
-			return lerr
+		var lerr error
+
+		root, lerr = viewLine(root, line, lineNum)
+		if lerr != nil {
+			return nil, lineNum, lerr
 		}
 
 		if err == io.EOF {
@@ -230,51 +219,104 @@ func (v *View) Init(fileName string) (err error) {
 		}
 	}
 
-	return nil
+	return root, lineNum, nil
 }
 
-func (v *View) viewLookup(unetaddr uint32) (info *ViewInfo) {
-	var (
-		bit  uint32    = 0x80000000
-		node *viewNode = &v.root
-	)
+func (v *View) Init(fileName string) (err error) {
+	root, lineNum, err := parseViewFile(fileName)
+	if err != nil {
+		return err
+	}
 
-	for node != nil {
-		if node.vinfo != nil {
-			info = node.vinfo
-		}
+	v.fileName = fileName
+	v.lineNum = lineNum
+	v.root.Store(root)
 
-		if unetaddr&bit != 0 {
-			node = node.right
-		} else {
-			node = node.left
-		}
+	return nil
+}
 
-		bit >>= 1
+// Lookup resolves addr, an IPv4 or IPv6 textual address, to the
+// longest-prefix-matching ViewInfo. The address family is detected from
+// addr itself. Lookup only ever loads v.root, so it is safe to call from
+// any number of goroutines concurrently, including while Add, Remove,
+// Replace or Reload are running.
+func (v *View) Lookup(addr string) (info *ViewInfo, err error) {
+	a, err := netip.ParseAddr(addr)
+	if err != nil {
+		return nil, errBadAddress
 	}
 
-	return
+	return v.LookupAddr(a), nil
 }
 
-func (v *View) Lookup(addr string) (info *ViewInfo, err error) {
-	baddr := []byte(addr)
+// LookupAddr resolves addr to the longest-prefix-matching ViewInfo. It
+// takes a parsed netip.Addr so callers that already hold one can look up
+// without allocating.
+func (v *View) LookupAddr(addr netip.Addr) *ViewInfo {
+	key, _ := viewKey(addr, 0)
 
-	ipfs := bytes.Split(baddr, []byte(viewIpaddrDelimite))
-	if len(ipfs) != 4 {
-		return nil, errBadAddress
-	}
+	return viewLookup(v.root.Load(), key, addr.Is4())
+}
 
-	var netaddr [4]uint8
+// Walk traverses the trie in sorted CIDR order, invoking fn once per
+// subnet. Returning false from fn stops the traversal early. Walk streams
+// results directly to fn instead of building a slice, so it stays cheap
+// on large tables.
+func (v *View) Walk(fn func(*ViewInfo) bool) {
+	viewWalk(v.root.Load(), fn)
+}
 
-	for i := 0; i < 4; i++ {
-		baddr, err := strconv.Atoi(string(ipfs[i]))
-		if err != nil {
-			return nil, errBadAddress
+// ReverseLookup returns every subnet registered under area, in sorted
+// CIDR order. If area is empty, all subnets are returned. It returns
+// ErrNotFound when a non-empty area matches nothing.
+func (v *View) ReverseLookup(area string) ([]*ViewInfo, error) {
+	var infos []*ViewInfo
+
+	v.Walk(func(info *ViewInfo) bool {
+		if area == "" || info.area == area {
+			infos = append(infos, info)
 		}
-		netaddr[i] = uint8(baddr)
+		return true
+	})
+
+	if area != "" && len(infos) == 0 {
+		return nil, ErrNotFound
 	}
 
-	unetaddr := viewUintAddr(netaddr)
+	return infos, nil
+}
+
+// Areas returns the distinct area names registered in the view, in
+// sorted CIDR order of first appearance.
+func (v *View) Areas() []string {
+	var areas []string
+
+	seen := make(map[string]bool)
+
+	v.Walk(func(info *ViewInfo) bool {
+		if !seen[info.area] {
+			seen[info.area] = true
+			areas = append(areas, info.area)
+		}
+		return true
+	})
+
+	return areas
+}
+
+// Count returns the number of registered subnets and the number of
+// distinct areas among them.
+func (v *View) Count() (nets int, areas int) {
+	seen := make(map[string]bool)
+
+	v.Walk(func(info *ViewInfo) bool {
+		nets++
+		if !seen[info.area] {
+			seen[info.area] = true
+			areas++
+		}
+		return true
+	})
 
-	return v.viewLookup(unetaddr), nil
+	return nets, areas
 }
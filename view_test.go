@@ -0,0 +1,123 @@
+package view
+
+import (
+	"net/netip"
+	"testing"
+)
+
+// buildView creates a fresh View preloaded with routes (subnet to area),
+// for tests that only care about its public surface.
+func buildView(t *testing.T, routes map[string]string) *View {
+	t.Helper()
+
+	v := New()
+
+	for cidr, area := range routes {
+		if err := v.Add(cidr, area); err != nil {
+			t.Fatalf("Add(%s): %v", cidr, err)
+		}
+	}
+
+	return v
+}
+
+// TestViewWalkShortCircuit checks that Walk stops visiting as soon as fn
+// returns false, instead of always running to completion.
+func TestViewWalkShortCircuit(t *testing.T) {
+	v := buildView(t, map[string]string{
+		"10.0.0.0/8":  "A",
+		"10.1.0.0/16": "B",
+		"10.2.0.0/16": "C",
+	})
+
+	var seen int
+
+	v.Walk(func(info *ViewInfo) bool {
+		seen++
+		return false
+	})
+
+	if seen != 1 {
+		t.Errorf("Walk visited %d routes after a false return, want 1", seen)
+	}
+}
+
+// TestViewReverseLookup checks that ReverseLookup filters by area, that
+// an empty area returns everything, and that a non-empty area matching
+// nothing is reported as ErrNotFound.
+func TestViewReverseLookup(t *testing.T) {
+	v := buildView(t, map[string]string{
+		"10.0.0.0/8":    "A",
+		"10.1.0.0/16":   "A",
+		"2001:db8::/32": "B",
+	})
+
+	infos, err := v.ReverseLookup("A")
+	if err != nil {
+		t.Fatalf("ReverseLookup(A): %v", err)
+	}
+	if len(infos) != 2 {
+		t.Errorf("ReverseLookup(A) = %d infos, want 2", len(infos))
+	}
+
+	all, err := v.ReverseLookup("")
+	if err != nil {
+		t.Fatalf("ReverseLookup(\"\"): %v", err)
+	}
+	if len(all) != 3 {
+		t.Errorf("ReverseLookup(\"\") = %d infos, want 3", len(all))
+	}
+
+	if _, err := v.ReverseLookup("missing"); err != ErrNotFound {
+		t.Errorf("ReverseLookup(missing) = %v, want ErrNotFound", err)
+	}
+}
+
+// TestViewAreasAndCount checks that Areas returns the distinct area names
+// and Count tallies both the number of routes and the number of distinct
+// areas among them.
+func TestViewAreasAndCount(t *testing.T) {
+	v := buildView(t, map[string]string{
+		"10.0.0.0/8":    "A",
+		"10.1.0.0/16":   "A",
+		"2001:db8::/32": "B",
+	})
+
+	areas := v.Areas()
+	if len(areas) != 2 {
+		t.Errorf("Areas() = %v, want 2 distinct areas", areas)
+	}
+
+	nets, areaCount := v.Count()
+	if nets != 3 {
+		t.Errorf("Count() nets = %d, want 3", nets)
+	}
+	if areaCount != 2 {
+		t.Errorf("Count() areas = %d, want 2", areaCount)
+	}
+}
+
+// TestViewLookupAddr checks that LookupAddr matches Lookup's result for
+// the same address, given directly as a netip.Addr.
+func TestViewLookupAddr(t *testing.T) {
+	v := buildView(t, map[string]string{"10.0.0.0/8": "A"})
+
+	got := v.LookupAddr(netip.MustParseAddr("10.1.2.3"))
+	if got == nil || got.area != "A" {
+		t.Errorf("LookupAddr(10.1.2.3) = %v, want area A", got)
+	}
+
+	if got := v.LookupAddr(netip.MustParseAddr("192.168.0.1")); got != nil {
+		t.Errorf("LookupAddr(192.168.0.1) = %v, want nil", got)
+	}
+}
+
+// TestViewLookupBadAddress checks that Lookup rejects an unparseable
+// address with errBadAddress instead of panicking or matching nothing.
+func TestViewLookupBadAddress(t *testing.T) {
+	v := New()
+
+	if _, err := v.Lookup("not-an-address"); err != errBadAddress {
+		t.Errorf("Lookup(not-an-address) = %v, want errBadAddress", err)
+	}
+}